@@ -1,28 +1,40 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/docker/distribution/reference"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/terrycain/kubectl-pdebug/profiles"
+	"github.com/terrycain/kubectl-pdebug/runner"
+	pdebugruntime "github.com/terrycain/kubectl-pdebug/runtime"
+	"github.com/terrycain/kubectl-pdebug/transfer"
 	corev1 "k8s.io/api/core/v1"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	watchtools "k8s.io/client-go/tools/watch"
 	"k8s.io/kubectl/pkg/cmd/attach"
@@ -43,22 +55,95 @@ type Patch struct {
 
 // CLI Flags.
 var CLI struct {
+	Verbose uint `short:"v" help:"number for the log level verbosity" default:"0"`
+
+	Debug     DebugCmd     `cmd:"" default:"withargs" help:"Patch an ephemeral debug container into one or more pods and optionally attach to it (default command)."`
+	List      ListCmd      `cmd:"" help:"List existing ephemeral debug containers on a pod, so a session can be reattached to instead of creating a new one."`
+	Bootstrap BootstrapCmd `cmd:"" hidden:"" name:"pdebug-bootstrap" help:"Internal entrypoint --runtime wraps the debug container's command with; not intended to be run directly."`
+}
+
+// DebugCmd implements kubectl-pdebug's default behaviour: patch an ephemeral
+// debug container into one or more pods and, if requested, attach to it.
+type DebugCmd struct {
 	// kubectl debug -it ephemeral-demo --image=busybox:1.28 --target=ephemeral-demo
-	Image       string            `required:"" help:"Container image to use for debug container."`
-	PodName     string            `arg:"" name:"pod"`
-	Target      string            `required:"" help:"When using an ephemeral container, target processes in this container name."`
-	Attach      bool              `name:"If true, wait for the container to start running, and then attach as if 'kubectl attach ...' were called.  Default false, unless '-i/--stdin' is set, in which case the default is true."`
-	Container   string            `short:"c" help:"Container name to use for debug container."`
-	Env         map[string]string `mapsep:"," help:"Environment variables to set in the container."`
-	Interactive bool              `short:"i" help:"Keep stdin open on the container(s) in the pod, even if nothing is attached."`
-	TTY         bool              `short:"t" help:"Allocate a TTY for the debugging container."`
-	Quiet       bool              `short:"q" help:"If true, suppress informational messages."`
-	Args        []string          `arg:"" required:"" help:"Command and args"`
-	Privileged  bool              `help:"Give extended privileges to this container"`
-	CapAdd      []string          `help:"Add Linux capabilities"`
-	CapDrop     []string          `help:"Drop Linux capabilities"`
-	Verbose     uint              `short:"v" help:"number for the log level verbosity" default:"0"`
-	Namespace   string            `short:"n" help:"If present, the namespace scope for this CLI request"`
+	Image         string            `required:"" help:"Container image to use for debug container."`
+	PodNames      []string          `arg:"" optional:"" name:"pod" help:"Pod name(s) to target. Omit and use -l/--selector or --all instead."`
+	Target        string            `required:"" help:"When using an ephemeral container, target processes in this container name."`
+	Attach        bool              `name:"If true, wait for the container to start running, and then attach as if 'kubectl attach ...' were called.  Default false, unless '-i/--stdin' is set, in which case the default is true."`
+	Container     string            `short:"c" help:"Container name to use for debug container. If this names an existing ephemeral container on the pod, reattach to it instead of patching a new one."`
+	Env           map[string]string `mapsep:"," help:"Environment variables to set in the container."`
+	Interactive   bool              `short:"i" help:"Keep stdin open on the container(s) in the pod, even if nothing is attached."`
+	TTY           bool              `short:"t" help:"Allocate a TTY for the debugging container."`
+	Quiet         bool              `short:"q" help:"If true, suppress informational messages."`
+	Args          []string          `arg:"" required:"" passthrough:"" help:"Command and args. Separate from pod name(s) with '--' when targeting more than one pod."`
+	Privileged    bool              `help:"Give extended privileges to this container"`
+	Profile       string            `help:"Debugging profile to apply (general, baseline, restricted, netadmin, sysadmin). Flags such as --cap-add/--cap-drop/--privileged override the profile's defaults."`
+	CapAdd        []string          `help:"Add Linux capabilities"`
+	CapDrop       []string          `help:"Drop Linux capabilities"`
+	CopyFrom      []string          `name:"copy-from" help:"Copy a local file/dir into the debug container once it starts, as LOCAL:REMOTE. Repeatable."`
+	Script        string            `help:"Local script to upload into the debug container and run as its command once it starts."`
+	Collect       []string          `help:"Remote path inside the debug container to tar up and extract to --output-dir when the session ends. Repeatable."`
+	OutputDir     string            `name:"output-dir" help:"Directory to write --collect artifacts to."`
+	RM            bool              `name:"rm" help:"Signal the debug container to exit when the session ends. Ephemeral containers can never actually be removed from a pod's spec."`
+	Runtime       string            `help:"Resolve the target container's init PID via the node's CRI socket (auto, containerd, crio, docker) instead of relying on shareProcessNamespace. Requires the pod to already mount the runtime socket in a 'cri-socket' volume, and --image to contain this kubectl-pdebug binary plus nsenter."`
+	Selector      string            `short:"l" name:"selector" help:"Label selector to select the pods to target, instead of naming them."`
+	All           bool              `name:"all" help:"Target every pod in the namespace."`
+	MaxConcurrent int               `name:"max-concurrent" default:"5" help:"Maximum number of pods to patch/attach concurrently."`
+	Namespace     string            `short:"n" help:"If present, the namespace scope for this CLI request"`
+}
+
+// ListCmd lists a pod's existing ephemeral debug containers, so a user can
+// find a --container name to reattach to with the default debug command
+// instead of starting a new session.
+type ListCmd struct {
+	PodName   string `arg:"" name:"pod" help:"Pod name to list debug containers on."`
+	Namespace string `short:"n" help:"If present, the namespace scope for this CLI request"`
+}
+
+// bootstrapBinaryPath is where --runtime expects the debug image to provide
+// this same kubectl-pdebug binary (e.g. a debug image built FROM one that
+// COPYs it in). Resolving the target's init PID via the CRI socket has to
+// happen inside the ephemeral container itself, so applyRuntimeResolution
+// wraps the container's command to exec this binary's hidden
+// "pdebug-bootstrap" command first.
+const bootstrapBinaryPath = "/usr/local/bin/kubectl-pdebug"
+
+// BootstrapCmd is the hidden entrypoint applyRuntimeResolution wraps a
+// --runtime debug container's command with: it resolves the target's init
+// PID via the CRI socket mounted at PDEBUG_CRI_SOCKET, then nsenters into
+// its namespaces and execs Args in place of itself.
+type BootstrapCmd struct {
+	Args []string `arg:"" optional:"" passthrough:"" help:"Command to run inside the target container's namespaces."`
+}
+
+// Run resolves PDEBUG_CONTAINER_ID's init PID over the CRI socket at
+// PDEBUG_CRI_SOCKET (both set by applyRuntimeResolution) and nsenters into
+// it, exec'ing Args in place of this process.
+func (c *BootstrapCmd) Run() error {
+	socketPath := os.Getenv("PDEBUG_CRI_SOCKET")
+	containerID := os.Getenv("PDEBUG_CONTAINER_ID")
+	if socketPath == "" || containerID == "" {
+		return errors.New("pdebug-bootstrap: PDEBUG_CRI_SOCKET/PDEBUG_CONTAINER_ID not set; this command is only meant to be run by a --runtime debug container")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pid, err := pdebugruntime.InitPID(ctx, socketPath, containerID)
+	if err != nil {
+		return fmt.Errorf("resolving target init pid: %w", err)
+	}
+
+	nsenterPath, err := osexec.LookPath("nsenter")
+	if err != nil {
+		return fmt.Errorf("looking up nsenter: %w", err)
+	}
+
+	args := c.Args
+	if len(args) == 0 {
+		args = []string{"/bin/sh"}
+	}
+	nsenterArgs := append([]string{"nsenter", "--target", strconv.Itoa(pid), "--mount", "--uts", "--ipc", "--net", "--pid", "--"}, args...)
+	return syscall.Exec(nsenterPath, nsenterArgs, os.Environ())
 }
 
 // getContainerStatusByName Extracts the status of a container from a Pod struct given the container name.
@@ -114,7 +199,7 @@ func waitForContainer(ns, podName, containerName string, clientset *kubernetes.C
 			if s.State.Running != nil || s.State.Terminated != nil {
 				return true, nil
 			}
-			if !CLI.Quiet && s.State.Waiting != nil && s.State.Waiting.Message != "" {
+			if !CLI.Debug.Quiet && s.State.Waiting != nil && s.State.Waiting.Message != "" {
 				fmt.Printf("container %s: %s\n", containerName, s.State.Waiting.Message)
 			}
 			return false, nil
@@ -131,7 +216,7 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 
-	kong.Parse(&CLI,
+	ctx := kong.Parse(&CLI,
 		kong.Name("kubectl pdebug"),
 		kong.Description("Similar to kubectl debug but supporting privileged containers"),
 		kong.UsageOnError())
@@ -140,126 +225,375 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
-	if len(CLI.Image) > 0 && !reference.ReferenceRegexp.MatchString(CLI.Image) {
-		fmt.Printf("invalid image name %q: %v\n", CLI.Image, reference.ErrReferenceInvalidFormat)
-		os.Exit(1)
+	ctx.FatalIfErrorf(ctx.Run())
+}
+
+// Run patches (and, if requested, attaches to) a debug container across
+// every target pod. This is kubectl-pdebug's default command.
+func (c *DebugCmd) Run() error {
+	if len(c.Image) > 0 && !reference.ReferenceRegexp.MatchString(c.Image) {
+		return fmt.Errorf("invalid image name %q: %w", c.Image, reference.ErrReferenceInvalidFormat)
 	}
 
-	if !CLI.Quiet {
-		fmt.Printf("Targeting container %q. If you don't see processes from this container it may be because the container runtime doesn't support this feature.\n", CLI.Target)
+	if !c.Quiet {
+		fmt.Printf("Targeting container %q. If you don't see processes from this container it may be because the container runtime doesn't support this feature.\n", c.Target)
 	}
 
-	if CLI.TTY && !CLI.Interactive {
-		fmt.Printf("-i/--stdin is required for containers with -t/--tty=true")
-		os.Exit(1)
+	if c.TTY && !c.Interactive {
+		return errors.New("-i/--stdin is required for containers with -t/--tty=true")
 	}
 
 	defaultConfigFlags := genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag().WithDiscoveryBurst(300).WithDiscoveryQPS(50.0)
 
 	f := cmdutil.NewFactory(cmdutil.NewMatchVersionFlags(defaultConfigFlags))
-	namespace := CLI.Namespace
+	namespace := c.Namespace
 	if len(namespace) == 0 {
 		var err error
 		namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
 		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to get namespace")
+			return fmt.Errorf("getting namespace: %w", err)
 		}
 	}
 
 	clientset, err := f.KubernetesClientSet()
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to generate clientset")
+		return fmt.Errorf("generating clientset: %w", err)
 	}
 
-	// Look for existing pod
-	pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), CLI.PodName, metav1.GetOptions{})
+	targets, err := runner.ListTargets(context.Background(), clientset, namespace, c.PodNames, c.Selector, c.All)
 	if err != nil {
 		var serr *kubeerrors.StatusError
 		if errors.As(err, &serr) && serr.Status().Reason == metav1.StatusReasonNotFound {
-			fmt.Println(serr.Status().Message)
-			os.Exit(1)
+			return errors.New(serr.Status().Message)
 		}
-		log.Fatal().Err(err).Msg("Failed to generate clientset")
+		return fmt.Errorf("resolving target pod(s): %w", err)
+	}
+	if len(targets) == 0 {
+		return errors.New("no pods matched")
 	}
 
-	foundTarget := false
-	foundExistingDebugContainer := false
-	for _, container := range pod.Spec.Containers {
-		if container.Name == CLI.Target {
-			foundTarget = true
+	multi := len(targets) > 1
+	if multi && c.Interactive {
+		return errors.New("-i/--stdin cannot be used when targeting more than one pod")
+	}
+	if multi && (len(c.CopyFrom) > 0 || c.Script != "" || len(c.Collect) > 0 || c.RM) {
+		return errors.New("--copy-from/--script/--collect/--output-dir/--rm are not supported when targeting more than one pod, since they run against a single attach session")
+	}
+
+	for _, pod := range targets {
+		if !hasContainer(pod, c.Target) {
+			return fmt.Errorf("pod %q does not have a container called %q", pod.Name, c.Target)
+		}
+	}
+
+	if len(c.Container) == 0 {
+		c.Container = fmt.Sprintf("debugger-%s", utilrand.String(5))
+		if !c.Quiet {
+			fmt.Printf("Defaulting debug container name to %s.\n", c.Container)
 		}
-		if container.Name == CLI.Container {
-			foundExistingDebugContainer = true
+	}
+
+	results := runner.Run(targets, c.MaxConcurrent, func(pod *corev1.Pod) error {
+		return debugPod(namespace, clientset, f, pod, multi)
+	})
+
+	failed := false
+	for _, result := range results {
+		if result.Err != nil {
+			failed = true
+			fmt.Printf("pod/%s: %v\n", result.Pod.Name, result.Err)
 		}
 	}
-	if !foundTarget {
-		fmt.Printf("Pod \"%s\" does not have a container called \"%s\"", CLI.PodName, CLI.Target)
-		os.Exit(1)
+	if failed {
+		return errors.New("one or more pods failed, see above")
 	}
-	if len(CLI.Container) == 0 {
-		CLI.Container = fmt.Sprintf("debugger-%s", utilrand.String(5))
-		if !CLI.Quiet {
-			fmt.Printf("Defaulting debug container name to %s.\n", CLI.Container)
+	return nil
+}
+
+// Run prints the pod's ephemeral debug containers and their status, so a
+// session started with the default command can be found again and reattached
+// to via --container.
+func (c *ListCmd) Run() error {
+	defaultConfigFlags := genericclioptions.NewConfigFlags(true).WithDeprecatedPasswordFlag().WithDiscoveryBurst(300).WithDiscoveryQPS(50.0)
+
+	f := cmdutil.NewFactory(cmdutil.NewMatchVersionFlags(defaultConfigFlags))
+	namespace := c.Namespace
+	if len(namespace) == 0 {
+		var err error
+		namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return fmt.Errorf("getting namespace: %w", err)
+		}
+	}
+
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("generating clientset: %w", err)
+	}
+
+	pod, err := clientset.CoreV1().Pods(namespace).Get(context.Background(), c.PodName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting pod %q: %w", c.PodName, err)
+	}
+
+	if len(pod.Spec.EphemeralContainers) == 0 {
+		fmt.Printf("No ephemeral debug containers found on pod %q.\n", c.PodName)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tAGE\tIMAGE\tTARGET")
+	for i := range pod.Spec.EphemeralContainers {
+		ec := &pod.Spec.EphemeralContainers[i]
+		status := getContainerStatusByName(pod, ec.Name)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ec.Name, containerState(status), containerAge(status), ec.Image, ec.TargetContainerName)
+	}
+	return w.Flush()
+}
+
+// containerState summarises an ephemeral container's status as one of
+// Running/Terminated/Waiting/Unknown, the way "kubectl get pods" does.
+func containerState(status *corev1.ContainerStatus) string {
+	switch {
+	case status == nil:
+		return "Unknown"
+	case status.State.Running != nil:
+		return "Running"
+	case status.State.Terminated != nil:
+		return fmt.Sprintf("Terminated (%s)", status.State.Terminated.Reason)
+	case status.State.Waiting != nil:
+		return fmt.Sprintf("Waiting (%s)", status.State.Waiting.Reason)
+	default:
+		return "Unknown"
+	}
+}
+
+// containerAge reports how long ago an ephemeral container started, in the
+// same human-readable form as "kubectl get pods".
+func containerAge(status *corev1.ContainerStatus) string {
+	switch {
+	case status == nil:
+		return "<unknown>"
+	case status.State.Running != nil:
+		return duration.HumanDuration(time.Since(status.State.Running.StartedAt.Time))
+	case status.State.Terminated != nil:
+		return duration.HumanDuration(time.Since(status.State.Terminated.StartedAt.Time))
+	default:
+		return "<unknown>"
+	}
+}
+
+// hasContainer reports whether pod has a (non-ephemeral) container called containerName.
+func hasContainer(pod *corev1.Pod, containerName string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == containerName {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEphemeralContainer reports whether pod already has an ephemeral
+// container called containerName, in which case debugPod reattaches to it
+// instead of patching a new one in.
+func hasEphemeralContainer(pod *corev1.Pod, containerName string) bool {
+	for _, ec := range pod.Spec.EphemeralContainers {
+		if ec.Name == containerName {
+			return true
+		}
+	}
+	return false
+}
+
+// debugPod patches in a new debug container, unless CLI.Debug.Container
+// names one that already exists on pod, in which case it reattaches to the
+// existing session instead. It then waits for and attaches to the debug
+// container if requested, or if --copy-from/--script/--collect/--rm need a
+// session to run against (DebugCmd.Run already rejects that combination for
+// more than one target pod). With more than one target pod, attach mode
+// falls back to streaming prefixed logs rather than an interactive attach
+// session.
+func debugPod(namespace string, clientset *kubernetes.Clientset, f cmdutil.Factory, pod *corev1.Pod, multi bool) error {
+	if hasEphemeralContainer(pod, CLI.Debug.Container) {
+		if !CLI.Debug.Quiet {
+			fmt.Printf("Reattaching to existing debug container %q on pod %q.\n", CLI.Debug.Container, pod.Name)
 		}
+	} else if err := patchPod(namespace, clientset, pod); err != nil {
+		return err
 	}
 
-	if !foundExistingDebugContainer {
-		patchPod(namespace, clientset)
+	needsSession := CLI.Debug.Interactive || CLI.Debug.Attach ||
+		len(CLI.Debug.CopyFrom) > 0 || CLI.Debug.Script != "" || len(CLI.Debug.Collect) > 0 || CLI.Debug.RM
+	if !needsSession {
+		return nil
 	}
-	if CLI.Interactive || CLI.Attach {
-		attachContainer(namespace, clientset, f)
+
+	if multi {
+		return streamLogs(namespace, clientset, pod.Name)
 	}
+	attachContainer(namespace, clientset, f, pod.Name)
+	return nil
 }
 
 // patchPod JSON Patch ephemeral container into pod.
-func patchPod(namespace string, clientset *kubernetes.Clientset) {
-	capAdd := make([]corev1.Capability, len(CLI.CapAdd))
-	capDrop := make([]corev1.Capability, len(CLI.CapDrop))
-	for i, addCap := range CLI.CapAdd {
+func patchPod(namespace string, clientset *kubernetes.Clientset, pod *corev1.Pod) error {
+	capAdd := make([]corev1.Capability, len(CLI.Debug.CapAdd))
+	capDrop := make([]corev1.Capability, len(CLI.Debug.CapDrop))
+	for i, addCap := range CLI.Debug.CapAdd {
 		capAdd[i] = corev1.Capability(strings.TrimPrefix(addCap, "CAP_"))
 	}
-	for i, dropCap := range CLI.CapDrop {
+	for i, dropCap := range CLI.Debug.CapDrop {
 		capDrop[i] = corev1.Capability(strings.TrimPrefix(dropCap, "CAP_"))
 	}
 
 	debugSpec := corev1.EphemeralContainer{
 		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
-			Name:    CLI.Container,
-			Image:   CLI.Image,
-			Command: CLI.Args,
-			SecurityContext: &corev1.SecurityContext{
-				Capabilities: &corev1.Capabilities{
-					Add:  capAdd,
-					Drop: capDrop,
-				},
-				Privileged: &CLI.Privileged,
-			},
-			Stdin:                    CLI.Interactive,
-			TTY:                      CLI.TTY,
+			Name:                     CLI.Debug.Container,
+			Image:                    CLI.Debug.Image,
+			Command:                  CLI.Debug.Args,
+			SecurityContext:          &corev1.SecurityContext{Capabilities: &corev1.Capabilities{}},
+			Stdin:                    CLI.Debug.Interactive,
+			TTY:                      CLI.Debug.TTY,
 			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
 		},
-		TargetContainerName: CLI.Target,
+		TargetContainerName: CLI.Debug.Target,
+	}
+
+	// Profile defaults are applied first so that explicit --cap-add/--cap-drop/--privileged
+	// flags below always take precedence over whatever the profile set.
+	if CLI.Debug.Profile != "" {
+		applier, ok := profiles.Registry[CLI.Debug.Profile]
+		if !ok {
+			return fmt.Errorf("unknown --profile %q, must be one of %v", CLI.Debug.Profile, profiles.Names())
+		}
+		applier.Apply(&debugSpec, pod)
+	}
+
+	sc := debugSpec.SecurityContext
+	sc.Capabilities.Add = append(sc.Capabilities.Add, capAdd...)
+	sc.Capabilities.Drop = append(sc.Capabilities.Drop, capDrop...)
+	if CLI.Debug.Privileged {
+		sc.Privileged = &CLI.Debug.Privileged
+		sc.AllowPrivilegeEscalation = &CLI.Debug.Privileged
+	}
+
+	if CLI.Debug.Runtime != "" {
+		if err := applyRuntimeResolution(&debugSpec, pod); err != nil {
+			return err
+		}
 	}
 
 	patch := Patch{Spec: EphemeralContainerPatch{EphemeralContainer: []corev1.EphemeralContainer{debugSpec}}}
 	patchBytes, err := json.Marshal(patch)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to generate patch set")
+		return fmt.Errorf("generating patch set: %w", err)
 	}
 
-	_, err = clientset.CoreV1().Pods(namespace).Patch(context.Background(), CLI.PodName, types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "ephemeralcontainers")
+	_, err = clientset.CoreV1().Pods(namespace).Patch(context.Background(), pod.Name, types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "ephemeralcontainers")
 	if err != nil {
 		var serr *kubeerrors.StatusError
 		if errors.As(err, &serr) && serr.Status().Reason == metav1.StatusReasonNotFound && serr.ErrStatus.Details.Name == "" {
-			fmt.Printf("ephemeral containers are disabled for this cluster (error from server: %q).\n", err)
-			os.Exit(1)
+			return fmt.Errorf("ephemeral containers are disabled for this cluster (error from server: %q)", err)
 		}
-		log.Fatal().Err(err).Msg("Failed to patch")
+		return fmt.Errorf("patching pod %q: %w", pod.Name, err)
 	}
+	return nil
 }
 
-// attachContainer Attach stdin/out/err to container like kubectl exec -it.
-func attachContainer(namespace string, clientset *kubernetes.Clientset, f cmdutil.Factory) {
+// runtimeSocketVolumeName is the volume name this tool looks for on the pod
+// to mount the chosen runtime's CRI socket into the debug container.
+// Ephemeral containers can't declare new pod volumes (the same constraint as
+// the sysadmin profile's host mounts), so the pod must already define a
+// hostPath volume with this name pointing at the socket.
+const runtimeSocketVolumeName = "cri-socket"
+
+// runtimeSocketMountPath is where the CRI socket volume is mounted inside
+// the debug container, regardless of its path on the node.
+const runtimeSocketMountPath = "/run/cri.sock"
+
+// applyRuntimeResolution wires up --runtime: it mounts the pod's existing
+// cri-socket volume (if present), sets env vars identifying the runtime
+// socket and target container ID, and wraps the debug container's command to
+// run through this binary's hidden "pdebug-bootstrap" entrypoint first, which
+// resolves the target's init PID over that socket and nsenters into its
+// namespaces before exec'ing the original command. This is needed when the
+// pod doesn't have shareProcessNamespace: true, so the debug image must
+// contain this same kubectl-pdebug binary at bootstrapBinaryPath plus
+// nsenter.
+func applyRuntimeResolution(ec *corev1.EphemeralContainer, pod *corev1.Pod) error {
+	name, err := pdebugruntime.Resolve(CLI.Debug.Runtime)
+	if err != nil {
+		return err
+	}
+
+	status := getContainerStatusByName(pod, CLI.Debug.Target)
+	if status == nil || status.ContainerID == "" {
+		return fmt.Errorf("could not determine a container ID for target %q", CLI.Debug.Target)
+	}
+	containerID := pdebugruntime.ContainerID(status.ContainerID)
+
+	found := false
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == runtimeSocketVolumeName {
+			ec.VolumeMounts = append(ec.VolumeMounts, corev1.VolumeMount{
+				Name:      v.Name,
+				MountPath: runtimeSocketMountPath,
+				ReadOnly:  true,
+			})
+			found = true
+			break
+		}
+	}
+	if !found {
+		fmt.Printf("warning: pod %q has no %q volume; mount the %s socket (%s) there for --runtime to work\n",
+			pod.Name, runtimeSocketVolumeName, name, pdebugruntime.Sockets[name])
+	}
+
+	ec.Env = append(ec.Env,
+		corev1.EnvVar{Name: "PDEBUG_RUNTIME", Value: string(name)},
+		corev1.EnvVar{Name: "PDEBUG_CRI_SOCKET", Value: runtimeSocketMountPath},
+		corev1.EnvVar{Name: "PDEBUG_CONTAINER_ID", Value: containerID},
+	)
+	ec.Command = append([]string{bootstrapBinaryPath, "pdebug-bootstrap", "--"}, ec.Command...)
+	return nil
+}
+
+// streamLogs follows the debug container's log stream on podName, writing
+// each line prefixed with "[pod/container]". Used instead of an interactive
+// attach when more than one pod is being targeted at once.
+func streamLogs(namespace string, clientset *kubernetes.Clientset, podName string) error {
+	podSpec, err := waitForContainer(namespace, podName, CLI.Debug.Container, clientset)
+	if err != nil {
+		return fmt.Errorf("waiting for container: %w", err)
+	}
+
+	status := getContainerStatusByName(podSpec, CLI.Debug.Container)
+	if status == nil {
+		return errors.New("failed to get container status")
+	}
+	if status.State.Terminated != nil {
+		return errors.New("ephemeral container terminated")
+	}
+
+	req := clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{Container: CLI.Debug.Container, Follow: true})
+	stream, err := req.Stream(context.Background())
+	if err != nil {
+		return fmt.Errorf("opening log stream: %w", err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		runner.Logf(podName, CLI.Debug.Container, "%s", scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// attachContainer waits for the debug container, uploads any --copy-from
+// paths/runs --script, and, if -i/--stdin or --attach was requested, attaches
+// to it like "kubectl exec -it" would. Either way, --collect/--rm cleanup
+// runs once the debug container's work is done.
+func attachContainer(namespace string, clientset *kubernetes.Clientset, f cmdutil.Factory, podName string) {
 	streams := genericclioptions.IOStreams{
 		In:     os.Stdin,
 		Out:    os.Stdout,
@@ -269,9 +603,9 @@ func attachContainer(namespace string, clientset *kubernetes.Clientset, f cmduti
 	opts := &attach.AttachOptions{
 		StreamOptions: exec.StreamOptions{
 			IOStreams: streams,
-			Stdin:     CLI.Interactive,
-			TTY:       CLI.TTY,
-			Quiet:     CLI.Quiet,
+			Stdin:     CLI.Debug.Interactive,
+			TTY:       CLI.Debug.TTY,
+			Quiet:     CLI.Debug.Quiet,
 		},
 		CommandName: "kubectl attach",
 
@@ -285,17 +619,17 @@ func attachContainer(namespace string, clientset *kubernetes.Clientset, f cmduti
 	opts.AttachFunc = attach.DefaultAttachFunc
 
 	// Wait for container
-	podSpec, err := waitForContainer(namespace, CLI.PodName, CLI.Container, clientset)
+	podSpec, err := waitForContainer(namespace, podName, CLI.Debug.Container, clientset)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to wait for container")
 	}
 
 	opts.Namespace = namespace
 	opts.Pod = podSpec
-	opts.PodName = CLI.PodName
-	opts.ContainerName = CLI.Container
+	opts.PodName = podName
+	opts.ContainerName = CLI.Debug.Container
 
-	status := getContainerStatusByName(podSpec, CLI.Container)
+	status := getContainerStatusByName(podSpec, CLI.Debug.Container)
 	if status == nil {
 		log.Fatal().Msg("Failed to get container status")
 		return // staticcheck does not recognise log.Fatal() as exiting
@@ -304,7 +638,100 @@ func attachContainer(namespace string, clientset *kubernetes.Clientset, f cmduti
 		log.Fatal().Msg("Ephemeral container terminated")
 	}
 
-	if err = opts.Run(); err != nil {
+	if err = copyFilesAndScript(config, clientset, namespace, podName); err != nil {
+		log.Fatal().Err(err).Msg("Failed to copy files/script into debug container")
+	}
+
+	if !CLI.Debug.Interactive && !CLI.Debug.Attach {
+		// Nothing asked us to actually attach; --copy-from/--script already
+		// ran above, so just run --collect/--rm cleanup and we're done.
+		cleanupSession(config, clientset, namespace, podName)
+		return
+	}
+
+	// The interrupt.Handler runs the cleanup phase once the attach session
+	// ends, whether it returns normally or is cut short by Ctrl-C, the same
+	// way waitForContainer's watch is wired to cancel via interrupt.Handler.
+	intr := interrupt.New(nil, func() {
+		cleanupSession(config, clientset, namespace, podName)
+	})
+	err = intr.Run(func() error {
+		return opts.Run()
+	})
+	if err != nil {
 		log.Fatal().Err(err).Msg("Could not attach to container")
 	}
 }
+
+// cleanupSession runs the --collect/--output-dir artifact extraction and, for
+// --rm, signals the debug container to exit. It runs once per session:
+// directly when nothing was attached, otherwise via attachContainer's
+// interrupt.Handler, on normal session exit and on Ctrl-C alike.
+func cleanupSession(config *restclient.Config, clientset *kubernetes.Clientset, namespace, podName string) {
+	if CLI.Debug.OutputDir != "" && len(CLI.Debug.Collect) > 0 {
+		dest := filepath.Join(CLI.Debug.OutputDir, fmt.Sprintf("%s-%s-%d.tar.gz", podName, CLI.Debug.Container, time.Now().Unix()))
+		if err := extractArtifacts(config, clientset, namespace, podName, dest); err != nil {
+			log.Error().Err(err).Msg("Failed to extract artifacts")
+		} else if !CLI.Debug.Quiet {
+			fmt.Printf("Wrote artifacts to %s\n", dest)
+		}
+	}
+
+	if CLI.Debug.RM {
+		if err := transfer.Run(config, clientset, namespace, podName, CLI.Debug.Container, []string{"sh", "-c", "kill -TERM 1"}); err != nil {
+			log.Debug().Err(err).Msg("Failed to signal debug container to exit")
+		}
+		fmt.Printf("warning: ephemeral containers cannot be removed from a pod's spec; %q will remain listed as Terminated on pod %q until the pod itself is deleted.\n", CLI.Debug.Container, podName)
+	}
+}
+
+// extractArtifacts tars up CLI.Debug.Collect from the debug container and writes
+// it, gzip-compressed, to dest.
+func extractArtifacts(config *restclient.Config, clientset *kubernetes.Clientset, namespace, podName, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dest, err)
+	}
+	defer f.Close()
+
+	return transfer.Download(config, clientset, namespace, podName, CLI.Debug.Container, CLI.Debug.Collect, f)
+}
+
+// copyFilesAndScript uploads any --copy-from paths and --script into the debug
+// container over an exec stream, and runs the uploaded script if one was given.
+func copyFilesAndScript(config *restclient.Config, clientset *kubernetes.Clientset, namespace, podName string) error {
+	if len(CLI.Debug.CopyFrom) > 0 {
+		specs := make([]transfer.Spec, len(CLI.Debug.CopyFrom))
+		for i, raw := range CLI.Debug.CopyFrom {
+			spec, err := transfer.ParseSpec(raw)
+			if err != nil {
+				return err
+			}
+			specs[i] = spec
+		}
+		if !CLI.Debug.Quiet {
+			fmt.Printf("Copying %d path(s) into %s/%s\n", len(specs), podName, CLI.Debug.Container)
+		}
+		if err := transfer.Upload(config, clientset, namespace, podName, CLI.Debug.Container, specs); err != nil {
+			return fmt.Errorf("copying files into container: %w", err)
+		}
+	}
+
+	if CLI.Debug.Script != "" {
+		if !CLI.Debug.Quiet {
+			fmt.Printf("Uploading and running script %s in %s/%s\n", CLI.Debug.Script, podName, CLI.Debug.Container)
+		}
+		remote, err := transfer.UploadScript(config, clientset, namespace, podName, CLI.Debug.Container, CLI.Debug.Script)
+		if err != nil {
+			return fmt.Errorf("uploading script: %w", err)
+		}
+		if err := transfer.Run(config, clientset, namespace, podName, CLI.Debug.Container, []string{"sh", remote}); err != nil {
+			return fmt.Errorf("running script: %w", err)
+		}
+	}
+
+	return nil
+}
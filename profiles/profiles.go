@@ -0,0 +1,122 @@
+// Package profiles implements the named debugging profiles accepted by
+// kubectl-pdebug's --profile flag, each describing a curated SecurityContext
+// (and, for sysadmin-style profiles, volume mounts) to apply to the
+// ephemeral debug container before it is patched into a pod.
+package profiles
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProfileApplier applies a profile's defaults onto an ephemeral container.
+// Implementations mutate ec in place; pod is the target pod, used by
+// sysadmin-style profiles to locate volumes already declared on the pod.
+type ProfileApplier interface {
+	Apply(ec *corev1.EphemeralContainer, pod *corev1.Pod)
+}
+
+// ApplierFunc adapts a plain function to a ProfileApplier.
+type ApplierFunc func(ec *corev1.EphemeralContainer, pod *corev1.Pod)
+
+// Apply calls f(ec, pod).
+func (f ApplierFunc) Apply(ec *corev1.EphemeralContainer, pod *corev1.Pod) {
+	f(ec, pod)
+}
+
+// Registry maps profile names, as accepted by --profile, to their ProfileApplier.
+var Registry = map[string]ProfileApplier{
+	"general":    ApplierFunc(applyGeneral),
+	"baseline":   ApplierFunc(applyBaseline),
+	"restricted": ApplierFunc(applyRestricted),
+	"netadmin":   ApplierFunc(applyNetadmin),
+	"sysadmin":   ApplierFunc(applySysadmin),
+}
+
+// Names returns the profile names in Registry, for use in help and error text.
+func Names() []string {
+	names := make([]string, 0, len(Registry))
+	for name := range Registry {
+		names = append(names, name)
+	}
+	return names
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func ensureSecurityContext(ec *corev1.EphemeralContainer) *corev1.SecurityContext {
+	if ec.SecurityContext == nil {
+		ec.SecurityContext = &corev1.SecurityContext{}
+	}
+	return ec.SecurityContext
+}
+
+func addCapabilities(ec *corev1.EphemeralContainer, caps ...corev1.Capability) {
+	sc := ensureSecurityContext(ec)
+	if sc.Capabilities == nil {
+		sc.Capabilities = &corev1.Capabilities{}
+	}
+	sc.Capabilities.Add = append(sc.Capabilities.Add, caps...)
+}
+
+// applyGeneral mirrors upstream kubectl debug's "general" profile: a
+// permissive default for most troubleshooting, adding SYS_PTRACE so the
+// debug container can inspect the target's processes.
+func applyGeneral(ec *corev1.EphemeralContainer, _ *corev1.Pod) {
+	addCapabilities(ec, "SYS_PTRACE")
+}
+
+// applyBaseline adds nothing beyond disabling privilege escalation, for
+// troubleshooting that doesn't require elevated capabilities.
+func applyBaseline(ec *corev1.EphemeralContainer, _ *corev1.Pod) {
+	ensureSecurityContext(ec).AllowPrivilegeEscalation = boolPtr(false)
+}
+
+// applyRestricted additionally enforces the Pod Security Standards
+// "restricted" profile: non-root, all capabilities dropped, RuntimeDefault
+// seccomp.
+func applyRestricted(ec *corev1.EphemeralContainer, _ *corev1.Pod) {
+	sc := ensureSecurityContext(ec)
+	sc.AllowPrivilegeEscalation = boolPtr(false)
+	sc.RunAsNonRoot = boolPtr(true)
+	if sc.Capabilities == nil {
+		sc.Capabilities = &corev1.Capabilities{}
+	}
+	sc.Capabilities.Drop = append(sc.Capabilities.Drop, "ALL")
+	sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// applyNetadmin grants the networking capabilities needed to inspect or
+// rewrite the target's network namespace (iptables, tc, packet capture).
+func applyNetadmin(ec *corev1.EphemeralContainer, _ *corev1.Pod) {
+	addCapabilities(ec, "NET_ADMIN", "NET_RAW")
+}
+
+// hostRootVolumeNames are pod volume names this tool recognises as exposing
+// the node's filesystem, in priority order. Ephemeral containers cannot
+// declare new pod volumes, so sysadmin mode can only mount what the pod
+// already has.
+var hostRootVolumeNames = []string{"host-root", "host-proc", "hostproc"}
+
+// applySysadmin grants SYS_ADMIN and privileged access, and mounts whichever
+// of hostRootVolumeNames the pod already declares so the debug container can
+// reach the node's root filesystem or /proc.
+func applySysadmin(ec *corev1.EphemeralContainer, pod *corev1.Pod) {
+	sc := ensureSecurityContext(ec)
+	sc.Privileged = boolPtr(true)
+	addCapabilities(ec, "SYS_ADMIN")
+
+	if pod == nil {
+		return
+	}
+	for _, name := range hostRootVolumeNames {
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == name {
+				ec.VolumeMounts = append(ec.VolumeMounts, corev1.VolumeMount{
+					Name:      v.Name,
+					MountPath: "/host",
+				})
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package profiles
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApplyGeneral(t *testing.T) {
+	ec := &corev1.EphemeralContainer{}
+	applyGeneral(ec, nil)
+
+	if ec.SecurityContext == nil || ec.SecurityContext.Capabilities == nil {
+		t.Fatalf("expected capabilities to be set, got %+v", ec.SecurityContext)
+	}
+	if !hasCapability(ec.SecurityContext.Capabilities.Add, "SYS_PTRACE") {
+		t.Errorf("expected SYS_PTRACE to be added, got %v", ec.SecurityContext.Capabilities.Add)
+	}
+}
+
+func TestApplyBaseline(t *testing.T) {
+	ec := &corev1.EphemeralContainer{}
+	applyBaseline(ec, nil)
+
+	if ec.SecurityContext == nil || ec.SecurityContext.AllowPrivilegeEscalation == nil {
+		t.Fatalf("expected AllowPrivilegeEscalation to be set, got %+v", ec.SecurityContext)
+	}
+	if *ec.SecurityContext.AllowPrivilegeEscalation {
+		t.Errorf("expected AllowPrivilegeEscalation to be false")
+	}
+}
+
+func TestApplyRestricted(t *testing.T) {
+	ec := &corev1.EphemeralContainer{}
+	applyRestricted(ec, nil)
+
+	sc := ec.SecurityContext
+	if sc == nil {
+		t.Fatalf("expected SecurityContext to be set")
+	}
+	if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+		t.Errorf("expected AllowPrivilegeEscalation=false, got %v", sc.AllowPrivilegeEscalation)
+	}
+	if sc.RunAsNonRoot == nil || !*sc.RunAsNonRoot {
+		t.Errorf("expected RunAsNonRoot=true, got %v", sc.RunAsNonRoot)
+	}
+	if !hasCapability(sc.Capabilities.Drop, "ALL") {
+		t.Errorf("expected ALL to be dropped, got %v", sc.Capabilities.Drop)
+	}
+	if sc.SeccompProfile == nil || sc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+		t.Errorf("expected RuntimeDefault seccomp profile, got %v", sc.SeccompProfile)
+	}
+}
+
+func TestApplyNetadmin(t *testing.T) {
+	ec := &corev1.EphemeralContainer{}
+	applyNetadmin(ec, nil)
+
+	if ec.SecurityContext == nil || ec.SecurityContext.Capabilities == nil {
+		t.Fatalf("expected capabilities to be set, got %+v", ec.SecurityContext)
+	}
+	for _, wantCap := range []corev1.Capability{"NET_ADMIN", "NET_RAW"} {
+		if !hasCapability(ec.SecurityContext.Capabilities.Add, wantCap) {
+			t.Errorf("expected %s to be added, got %v", wantCap, ec.SecurityContext.Capabilities.Add)
+		}
+	}
+}
+
+func TestApplySysadmin(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{Name: "host-proc"}, {Name: "unrelated"}},
+		},
+	}
+	ec := &corev1.EphemeralContainer{}
+	applySysadmin(ec, pod)
+
+	sc := ec.SecurityContext
+	if sc == nil || sc.Privileged == nil || !*sc.Privileged {
+		t.Fatalf("expected Privileged=true, got %+v", sc)
+	}
+	if !hasCapability(sc.Capabilities.Add, "SYS_ADMIN") {
+		t.Errorf("expected SYS_ADMIN to be added, got %v", sc.Capabilities.Add)
+	}
+
+	if len(ec.VolumeMounts) != 1 || ec.VolumeMounts[0].Name != "host-proc" {
+		t.Errorf("expected only the host-proc volume to be mounted, got %v", ec.VolumeMounts)
+	}
+}
+
+func TestApplySysadminNoMatchingVolume(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Volumes: []corev1.Volume{{Name: "unrelated"}}}}
+	ec := &corev1.EphemeralContainer{}
+	applySysadmin(ec, pod)
+
+	if len(ec.VolumeMounts) != 0 {
+		t.Errorf("expected no volume mounts, got %v", ec.VolumeMounts)
+	}
+}
+
+func TestApplySysadminNilPod(t *testing.T) {
+	ec := &corev1.EphemeralContainer{}
+	applySysadmin(ec, nil)
+
+	if ec.SecurityContext == nil || ec.SecurityContext.Privileged == nil || !*ec.SecurityContext.Privileged {
+		t.Fatalf("expected Privileged=true even with a nil pod, got %+v", ec.SecurityContext)
+	}
+}
+
+func TestRegistryAndNames(t *testing.T) {
+	names := Names()
+	if len(names) != len(Registry) {
+		t.Fatalf("expected Names() to return %d entries, got %d", len(Registry), len(names))
+	}
+	for _, want := range []string{"general", "baseline", "restricted", "netadmin", "sysadmin"} {
+		if _, ok := Registry[want]; !ok {
+			t.Errorf("expected Registry to contain %q", want)
+		}
+	}
+}
+
+func hasCapability(caps []corev1.Capability, want corev1.Capability) bool {
+	for _, c := range caps {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
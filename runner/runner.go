@@ -0,0 +1,84 @@
+// Package runner resolves which pods a kubectl-pdebug invocation should
+// target (by name, label selector, or --all) and fans out per-pod work
+// across them with bounded concurrency.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ListTargets resolves the pods to target: the explicitly named podNames if
+// any were given, otherwise every pod matching selector, or (with all and no
+// selector) every pod in the namespace.
+func ListTargets(ctx context.Context, clientset *kubernetes.Clientset, namespace string, podNames []string, selector string, all bool) ([]*corev1.Pod, error) {
+	if len(podNames) > 0 {
+		pods := make([]*corev1.Pod, 0, len(podNames))
+		for _, name := range podNames {
+			pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("getting pod %q: %w", name, err)
+			}
+			pods = append(pods, pod)
+		}
+		return pods, nil
+	}
+
+	if selector == "" && !all {
+		return nil, fmt.Errorf("specify pod name(s), -l/--selector, or --all")
+	}
+
+	list, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	pods := make([]*corev1.Pod, len(list.Items))
+	for i := range list.Items {
+		pods[i] = &list.Items[i]
+	}
+	return pods, nil
+}
+
+// Result is the outcome of running a Work func against a single pod.
+type Result struct {
+	Pod *corev1.Pod
+	Err error
+}
+
+// Work is the per-pod callback fanned out by Run.
+type Work func(pod *corev1.Pod) error
+
+// Run calls work for every target, running up to maxConcurrent at a time,
+// and returns one Result per target once all have finished.
+func Run(targets []*corev1.Pod, maxConcurrent int, work Work) []Result {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, maxConcurrent)
+	var wg sync.WaitGroup
+	for i, pod := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pod *corev1.Pod) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = Result{Pod: pod, Err: work(pod)}
+		}(i, pod)
+	}
+	wg.Wait()
+	return results
+}
+
+// Logf writes a line to stdout prefixed with "[pod/container]", used to tell
+// apart concurrently streamed output from multiple debug containers.
+func Logf(podName, containerName, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stdout, "[%s/%s] "+format+"\n", append([]interface{}{podName, containerName}, args...)...)
+}
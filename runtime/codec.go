@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// rawCodec lets criClient.InitPID Invoke() the ContainerStatus RPC with plain
+// []byte request/response values instead of proto.Message, since cri.go
+// already hand-encodes/decodes the protobuf wire format itself.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pdebug-raw codec: expected []byte, got %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	dst, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("pdebug-raw codec: expected *[]byte, got %T", v)
+	}
+	*dst = append((*dst)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return rawCodecName
+}
+
+func init() {
+	encoding.RegisterCodec(rawCodec{})
+}
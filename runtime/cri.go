@@ -0,0 +1,231 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// criClient is a hand-rolled client for a single CRI v1 RuntimeService RPC,
+// ContainerStatus, so callers only need this small file rather than pulling
+// in the full generated k8s.io/cri-api package for one field lookup. It
+// speaks the protobuf wire format directly for just the two messages this
+// package cares about.
+type criClient struct {
+	conn *grpc.ClientConn
+}
+
+func dialCRI(ctx context.Context, socketPath string) (*criClient, error) {
+	conn, err := grpc.DialContext(ctx, "unix://"+socketPath, grpc.WithInsecure(), grpc.WithBlock()) //nolint:staticcheck // matches the dial style of the real cri-api client
+	if err != nil {
+		return nil, fmt.Errorf("dialing CRI socket %s: %w", socketPath, err)
+	}
+	return &criClient{conn: conn}, nil
+}
+
+func (c *criClient) Close() error {
+	return c.conn.Close()
+}
+
+// containerInfo is the subset of the verbose "info" map entry that CRI
+// implementations (containerd, CRI-O) populate on ContainerStatus; it is a
+// JSON blob keyed "info" containing (among other things) the container's
+// init PID.
+type containerInfo struct {
+	PID int `json:"pid"`
+}
+
+// InitPID calls RuntimeService/ContainerStatus(verbose=true) for containerID
+// and extracts the container's init PID from the verbose "info" payload.
+func (c *criClient) InitPID(ctx context.Context, containerID string) (int, error) {
+	req := marshalContainerStatusRequest(containerID, true)
+
+	var respBytes []byte
+	if err := c.conn.Invoke(ctx, "/runtime.v1.RuntimeService/ContainerStatus", req, &respBytes,
+		grpc.CallContentSubtype(rawCodecName)); err != nil {
+		return 0, fmt.Errorf("ContainerStatus(%s): %w", containerID, err)
+	}
+
+	info, err := unmarshalContainerStatusInfo(respBytes)
+	if err != nil {
+		return 0, fmt.Errorf("decoding ContainerStatusResponse: %w", err)
+	}
+	raw, ok := info["info"]
+	if !ok {
+		return 0, fmt.Errorf("container %s: runtime did not return verbose info", containerID)
+	}
+
+	var ci containerInfo
+	if err := json.Unmarshal([]byte(raw), &ci); err != nil {
+		return 0, fmt.Errorf("parsing verbose info JSON: %w", err)
+	}
+	if ci.PID == 0 {
+		return 0, fmt.Errorf("container %s: runtime did not report a pid", containerID)
+	}
+	return ci.PID, nil
+}
+
+// --- minimal protobuf wire encoding for ContainerStatusRequest/Response ---
+// Only the fields this package reads/writes are implemented; everything else
+// on the wire is skipped rather than parsed.
+
+const (
+	wireVarint      = 0
+	wireLengthDelim = 2
+)
+
+func marshalContainerStatusRequest(containerID string, verbose bool) []byte {
+	var buf bytes.Buffer
+	writeTag(&buf, 1, wireLengthDelim)
+	writeVarint(&buf, uint64(len(containerID)))
+	buf.WriteString(containerID)
+	if verbose {
+		writeTag(&buf, 2, wireVarint)
+		writeVarint(&buf, 1)
+	}
+	return buf.Bytes()
+}
+
+// unmarshalContainerStatusInfo extracts just the "info" map (field 2 of
+// ContainerStatusResponse) from the raw response bytes.
+func unmarshalContainerStatusInfo(data []byte) (map[string]string, error) {
+	info := map[string]string{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		fieldNum, wireType, err := readTag(r)
+		if err != nil {
+			return nil, err
+		}
+		if fieldNum == 2 && wireType == wireLengthDelim {
+			entry, err := readBytes(r)
+			if err != nil {
+				return nil, err
+			}
+			k, v, err := unmarshalStringMapEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			info[k] = v
+			continue
+		}
+		if err := skipField(r, wireType); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+func unmarshalStringMapEntry(data []byte) (key, value string, err error) {
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		fieldNum, wireType, err := readTag(r)
+		if err != nil {
+			return "", "", err
+		}
+		switch {
+		case fieldNum == 1 && wireType == wireLengthDelim:
+			b, err := readBytes(r)
+			if err != nil {
+				return "", "", err
+			}
+			key = string(b)
+		case fieldNum == 2 && wireType == wireLengthDelim:
+			b, err := readBytes(r)
+			if err != nil {
+				return "", "", err
+			}
+			value = string(b)
+		default:
+			if err := skipField(r, wireType); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	return key, value, nil
+}
+
+func writeTag(buf *bytes.Buffer, fieldNum int, wireType int) {
+	writeVarint(buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	for v >= 0x80 {
+		buf.WriteByte(byte(v) | 0x80)
+		v >>= 7
+	}
+	buf.WriteByte(byte(v))
+}
+
+func readVarint(r *bytes.Reader) (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return result, nil
+		}
+		shift += 7
+	}
+}
+
+func readTag(r *bytes.Reader) (fieldNum, wireType int, err error) {
+	v, err := readVarint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 0x7), nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := readFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func readFull(r *bytes.Reader, b []byte) (int, error) {
+	total := 0
+	for total < len(b) {
+		n, err := r.Read(b[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func skipField(r *bytes.Reader, wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := readVarint(r)
+		return err
+	case wireLengthDelim:
+		_, err := readBytes(r)
+		return err
+	case 1: // 64-bit
+		_, err := readFull(r, make([]byte, 8))
+		return err
+	case 5: // 32-bit
+		_, err := readFull(r, make([]byte, 4))
+		return err
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+}
+
+// rawCodecName selects the rawCodec registered in codec.go, letting
+// us Invoke() with a []byte request/response instead of a proto.Message.
+const rawCodecName = "pdebug-raw"
@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"bytes"
+	"testing"
+)
+
+// encodeStringMapEntry builds the wire bytes for a single protobuf
+// map<string,string> entry (key at field 1, value at field 2), mirroring
+// what unmarshalStringMapEntry decodes.
+func encodeStringMapEntry(key, value string) []byte {
+	var buf bytes.Buffer
+	writeTag(&buf, 1, wireLengthDelim)
+	writeVarint(&buf, uint64(len(key)))
+	buf.WriteString(key)
+	writeTag(&buf, 2, wireLengthDelim)
+	writeVarint(&buf, uint64(len(value)))
+	buf.WriteString(value)
+	return buf.Bytes()
+}
+
+// encodeContainerStatusResponse builds a synthetic CRI v1 ContainerStatusResponse,
+// with status (field 1, skipped here) omitted and a single info map entry
+// (field 2) carrying key/value.
+func encodeContainerStatusResponse(infoKey, infoValue string) []byte {
+	entry := encodeStringMapEntry(infoKey, infoValue)
+
+	var buf bytes.Buffer
+	writeTag(&buf, 2, wireLengthDelim)
+	writeVarint(&buf, uint64(len(entry)))
+	buf.Write(entry)
+	return buf.Bytes()
+}
+
+func TestUnmarshalContainerStatusInfo(t *testing.T) {
+	data := encodeContainerStatusResponse("info", `{"pid":4242}`)
+
+	info, err := unmarshalContainerStatusInfo(data)
+	if err != nil {
+		t.Fatalf("unmarshalContainerStatusInfo returned error: %v", err)
+	}
+
+	raw, ok := info["info"]
+	if !ok {
+		t.Fatalf("expected an \"info\" entry, got %v", info)
+	}
+	if raw != `{"pid":4242}` {
+		t.Errorf("expected raw info JSON to round-trip, got %q", raw)
+	}
+}
+
+func TestUnmarshalContainerStatusInfoSkipsUnknownFields(t *testing.T) {
+	// field 1 ("status") is a length-delimited field this package doesn't
+	// care about; it must be skipped rather than mistaken for "info".
+	var statusField bytes.Buffer
+	writeTag(&statusField, 1, wireLengthDelim)
+	writeVarint(&statusField, 3)
+	statusField.WriteString("xyz")
+
+	data := append(statusField.Bytes(), encodeContainerStatusResponse("info", `{"pid":7}`)...)
+
+	info, err := unmarshalContainerStatusInfo(data)
+	if err != nil {
+		t.Fatalf("unmarshalContainerStatusInfo returned error: %v", err)
+	}
+	if info["info"] != `{"pid":7}` {
+		t.Errorf("expected info entry to survive an interleaved unknown field, got %v", info)
+	}
+}
@@ -0,0 +1,70 @@
+// Package runtime resolves the init PID of a target container directly from
+// the node's container runtime via the CRI RuntimeService.ContainerStatus
+// RPC. This is used as a fallback for pods that don't run with
+// shareProcessNamespace: true, where the ephemeral container otherwise can't
+// see the target's processes to nsenter into them. It supports containerd,
+// CRI-O, and dockershim-compatible CRI endpoints, selected via --runtime.
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Name identifies a container runtime flavour, as accepted by --runtime.
+type Name string
+
+const (
+	Auto       Name = "auto"
+	Containerd Name = "containerd"
+	CRIO       Name = "crio"
+	Docker     Name = "docker"
+)
+
+// Sockets maps each non-auto Name to the CRI socket path it listens on by
+// convention. A pod wanting --runtime support must already mount this path
+// into a volume named "cri-socket", since ephemeral containers can't
+// declare new pod volumes of their own.
+var Sockets = map[Name]string{
+	Containerd: "/run/containerd/containerd.sock",
+	CRIO:       "/var/run/crio/crio.sock",
+	Docker:     "/var/run/dockershim.sock",
+}
+
+// Resolve turns a --runtime flag value (including "auto") into the concrete
+// Name whose socket should be used. "auto" assumes containerd, the most
+// common runtime on current Kubernetes versions; pass an explicit value to
+// override.
+func Resolve(flag string) (Name, error) {
+	name := Name(strings.ToLower(flag))
+	if name == Auto {
+		return Containerd, nil
+	}
+	if _, ok := Sockets[name]; !ok {
+		return "", fmt.Errorf("unknown --runtime %q, must be one of auto, containerd, crio, docker", flag)
+	}
+	return name, nil
+}
+
+// ContainerID strips the "<runtime>://" scheme prefix Kubernetes stores on
+// ContainerStatus.ContainerID (e.g. "containerd://abcd...") down to the bare
+// ID the CRI API expects.
+func ContainerID(statusContainerID string) string {
+	if i := strings.Index(statusContainerID, "://"); i != -1 {
+		return statusContainerID[i+3:]
+	}
+	return statusContainerID
+}
+
+// InitPID dials the CRI socket at socketPath (as seen by the caller -
+// typically the ephemeral container, where the node's socket has been
+// bind-mounted) and returns containerID's init PID.
+func InitPID(ctx context.Context, socketPath, containerID string) (int, error) {
+	client, err := dialCRI(ctx, socketPath)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	return client.InitPID(ctx, containerID)
+}
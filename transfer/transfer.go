@@ -0,0 +1,195 @@
+// Package transfer streams local files and scripts into a running container
+// over an exec SPDY stream, mirroring how "kubectl cp" uploads a tar archive
+// and unpacks it with "tar xf -" on the remote side.
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// Spec describes a local path to copy to a remote path inside a container,
+// mirroring kubectl cp's LOCAL:REMOTE syntax.
+type Spec struct {
+	Local  string
+	Remote string
+}
+
+// ParseSpec parses a "LOCAL:REMOTE" --copy-from flag value into a Spec.
+func ParseSpec(raw string) (Spec, error) {
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Spec{}, fmt.Errorf("invalid --copy-from value %q, expected LOCAL:REMOTE", raw)
+	}
+	return Spec{Local: parts[0], Remote: parts[1]}, nil
+}
+
+// Upload tars up the given specs and streams them into container via
+// "tar xf -", the same mechanism kubectl cp uses to upload files.
+func Upload(config *restclient.Config, clientset *kubernetes.Clientset, namespace, pod, container string, specs []Spec) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, spec := range specs {
+		if err := addToTar(tw, spec.Local, spec.Remote); err != nil {
+			return fmt.Errorf("archiving %s: %w", spec.Local, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing tar archive: %w", err)
+	}
+
+	return execStream(config, clientset, namespace, pod, container, []string{"tar", "xf", "-", "-C", "/"}, &buf, os.Stdout)
+}
+
+// Download tars up the given remote paths inside container via "tar cf -"
+// and gzip-compresses the resulting stream into dest, the same mechanism
+// kubectl cp uses to download files.
+func Download(config *restclient.Config, clientset *kubernetes.Clientset, namespace, pod, container string, remotePaths []string, dest io.Writer) error {
+	args := make([]string, 0, len(remotePaths)+3)
+	args = append(args, "tar", "cf", "-", "-C", "/")
+	for _, p := range remotePaths {
+		args = append(args, strings.TrimPrefix(p, "/"))
+	}
+
+	gz := gzip.NewWriter(dest)
+	if err := execStream(config, clientset, namespace, pod, container, args, nil, gz); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// UploadScript uploads localPath to /tmp/<basename> inside container and
+// returns its remote path, so callers can run it as the container's
+// effective command.
+func UploadScript(config *restclient.Config, clientset *kubernetes.Clientset, namespace, pod, container, localPath string) (string, error) {
+	remote := path.Join("/tmp", filepath.Base(localPath))
+	if err := Upload(config, clientset, namespace, pod, container, []Spec{{Local: localPath, Remote: remote}}); err != nil {
+		return "", err
+	}
+	return remote, nil
+}
+
+// Run executes command inside container, streaming its stdout to os.Stdout,
+// returning an error that carries the exit code and captured stderr if it
+// exits non-zero.
+func Run(config *restclient.Config, clientset *kubernetes.Clientset, namespace, pod, container string, command []string) error {
+	return execStream(config, clientset, namespace, pod, container, command, nil, os.Stdout)
+}
+
+func addToTar(tw *tar.Writer, localPath, remotePath string) error {
+	info, err := os.Lstat(localPath)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+		return addFile(tw, localPath, info, remotePath)
+	}
+
+	// filepath.Walk uses Lstat for every entry it visits (including nested
+	// symlinks), so addFile below sees the same symlink-aware FileInfo it
+	// would for a top-level path.
+	return filepath.Walk(localPath, func(p string, fi os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, p, fi, path.Join(remotePath, filepath.ToSlash(rel)))
+	})
+}
+
+// addFile writes a single tar entry for localPath/info. Symlinks are written
+// as TypeSymlink headers pointing at their target, with no body; everything
+// else (including directories, which get a header but no body) follows the
+// target's content into the archive.
+func addFile(tw *tar.Writer, localPath string, info os.FileInfo, remotePath string) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(localPath)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = strings.TrimPrefix(remotePath, "/")
+		return tw.WriteHeader(hdr)
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = strings.TrimPrefix(remotePath, "/")
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// execStream runs command inside container, piping stdin to it and command's
+// stdout to stdout, and turns a non-zero exit code into an error carrying
+// that code.
+func execStream(config *restclient.Config, clientset *kubernetes.Clientset, namespace, pod, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec")
+	req.VersionedParams(&corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     stdin != nil,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("creating exec stream: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	err = executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	var exitErr utilexec.CodeExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("%v exited with code %d: %s", command, exitErr.ExitStatus(), stderr.String())
+	}
+	if err != nil {
+		return fmt.Errorf("%v failed: %w (%s)", command, err, stderr.String())
+	}
+	return nil
+}
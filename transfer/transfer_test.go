@@ -0,0 +1,73 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddToTarDirectoryWithSymlink(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "real.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatalf("writing real.txt: %v", err)
+	}
+	if err := os.Symlink("real.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := addToTar(tw, dir, "/dest"); err != nil {
+		t.Fatalf("addToTar returned error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	found := map[string]*tar.Header{}
+	var bodies = map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		found[hdr.Name] = hdr
+		body := make([]byte, hdr.Size)
+		if _, err := tr.Read(body); err != nil && hdr.Size > 0 {
+			t.Fatalf("reading body of %s: %v", hdr.Name, err)
+		}
+		bodies[hdr.Name] = body
+	}
+
+	linkHdr, ok := found["dest/link.txt"]
+	if !ok {
+		t.Fatalf("expected dest/link.txt in archive, got %v", found)
+	}
+	if linkHdr.Typeflag != tar.TypeSymlink {
+		t.Errorf("expected dest/link.txt to be a symlink entry, got typeflag %v", linkHdr.Typeflag)
+	}
+	if linkHdr.Linkname != "real.txt" {
+		t.Errorf("expected link target real.txt, got %q", linkHdr.Linkname)
+	}
+	if linkHdr.Size != 0 {
+		t.Errorf("expected symlink entry to carry no body, got size %d", linkHdr.Size)
+	}
+	if len(bodies["dest/link.txt"]) != 0 {
+		t.Errorf("expected symlink entry body to be empty, got %q", bodies["dest/link.txt"])
+	}
+
+	fileHdr, ok := found["dest/real.txt"]
+	if !ok {
+		t.Fatalf("expected dest/real.txt in archive, got %v", found)
+	}
+	if fileHdr.Typeflag != tar.TypeReg {
+		t.Errorf("expected dest/real.txt to be a regular file entry, got typeflag %v", fileHdr.Typeflag)
+	}
+	if string(bodies["dest/real.txt"]) != "hello" {
+		t.Errorf("expected dest/real.txt body %q, got %q", "hello", bodies["dest/real.txt"])
+	}
+}